@@ -8,6 +8,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-redis/redis/v8"
+	"golang.org/x/sync/errgroup"
 
 	"github.com/equinor/oneseismic/api/internal/auth"
 	"github.com/equinor/oneseismic/api/internal/message"
@@ -89,6 +90,15 @@ func (be *BasicEndpoint) Root(ctx *gin.Context) {
 	})
 }
 
+/*
+ * listManifestWorkers bounds how many manifest fetches List runs
+ * concurrently. Every list request, filtered or not, fetches each cube's
+ * manifest to filter and sort on, so this keeps a storage account holding
+ * thousands of cubes from turning every list request into a fan-out
+ * storm.
+ */
+const listManifestWorkers = 16
+
 func (be *BasicEndpoint) List(ctx *gin.Context) {
 	pid := ctx.GetString("pid")
 	ep := ctx.GetString("Endpoint")
@@ -99,19 +109,98 @@ func (be *BasicEndpoint) List(ctx *gin.Context) {
 		return
 	}
 
+	query, err := ParseListQuery(
+		ctx.Query("filter"),
+		ctx.Query("sort"),
+		ctx.Query("limit"),
+		ctx.Query("cursor"),
+	)
+	if err != nil {
+		log.Printf("pid=%s %v", pid, err)
+		ctx.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	filter, err := parseFilter(query.Filter)
+	if err != nil {
+		log.Printf("pid=%s %v", pid, err)
+		ctx.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	after, err := decodeCursor(query.Cursor)
+	if err != nil {
+		log.Printf("pid=%s %v", pid, err)
+		ctx.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
 	token := ctx.GetString("Token")
-	cubes, err := util.ListCubes(ctx, endpoint, token)
+	guids, err := util.ListCubes(ctx, endpoint, token)
 	if err != nil {
 		log.Printf("pid=%s, %v", pid, err)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	/*
+	 * A single cube with a missing or malformed manifest must not take
+	 * down the whole listing: that cube is logged and skipped, rather
+	 * than failing every caller's every page until it's fixed.
+	 */
+	cubes := make([]cubeMeta, len(guids))
+	fetched := make([]bool, len(guids))
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(listManifestWorkers)
+	for i, guid := range guids {
+		i, guid := i, guid
+		g.Go(func() error {
+			m, err := util.GetManifest(gctx, ep, guid)
+			if err != nil {
+				log.Printf("pid=%s guid=%s %v", pid, guid, err)
+				return nil
+			}
+			cubes[i] = metaFromManifest(guid, m)
+			fetched[i] = true
+			return nil
+		})
+	}
+	g.Wait()
+
+	matched := cubes[:0]
+	for i, c := range cubes {
+		if fetched[i] && filter.match(c) {
+			matched = append(matched, c)
+		}
+	}
+	sortCubes(matched, query.Sort)
+
+	page := matched
+	if after.guid != "" {
+		for i, c := range matched {
+			if sortKeyOf(c, query.Sort) == after.sortKey && c.guid == after.guid {
+				page = matched[i+1:]
+				break
+			}
+		}
+	}
+
+	var next string
+	if len(page) > query.Limit {
+		last := page[query.Limit-1]
+		next = encodeCursor(listCursor{sortKey: sortKeyOf(last, query.Sort), guid: last.guid})
+		page = page[:query.Limit]
 	}
 
-	links := make(map[string]string)
-	for _, cube := range cubes {
-		links[cube] = fmt.Sprintf("query/%s", cube)
+	links := make(map[string]string, len(page))
+	for _, c := range page {
+		links[c.guid] = fmt.Sprintf("query/%s", c.guid)
 	}
 
 	ctx.JSON(http.StatusOK, gin.H {
 		"links": links,
+		"next":  next,
+		"count": len(matched),
 	})
 }
 
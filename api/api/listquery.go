@@ -0,0 +1,319 @@
+package api
+
+import (
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/equinor/oneseismic/api/internal/util"
+)
+
+const defaultListLimit = 100
+
+/*
+ * ListQuery is the parsed form of the query params accepted by
+ * BasicEndpoint.List: filter expression, sort key, page size, and an
+ * opaque continuation cursor.
+ */
+type ListQuery struct {
+	Filter string
+	Sort   string
+	Limit  int
+	Cursor string
+}
+
+/*
+ * ParseListQuery reads filter/sort/limit/cursor from raw query params,
+ * applying oneseismic's defaults (no filter, sort by name, defaultListLimit).
+ */
+func ParseListQuery(filter, sort, limit, cursor string) (ListQuery, error) {
+	q := ListQuery{
+		Filter: filter,
+		Sort:   sort,
+		Limit:  defaultListLimit,
+		Cursor: cursor,
+	}
+
+	if q.Sort == "" {
+		q.Sort = "name"
+	}
+
+	if limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			return ListQuery{}, fmt.Errorf("invalid limit=%q", limit)
+		}
+		q.Limit = n
+	}
+
+	return q, nil
+}
+
+/*
+ * cubeMeta is the subset of a cube's manifest that listing can filter
+ * and sort on.
+ */
+type cubeMeta struct {
+	guid    string
+	name    string
+	dim0    int
+	updated time.Time
+}
+
+func metaFromManifest(guid string, m *util.Manifest) cubeMeta {
+	dim0 := 0
+	if len(m.Dimensions) > 0 {
+		dim0 = len(m.Dimensions[0])
+	}
+	return cubeMeta{
+		guid:    guid,
+		name:    m.Name,
+		dim0:    dim0,
+		updated: m.Updated,
+	}
+}
+
+/*
+ * filterClause is a single `field op value` comparison, e.g.
+ * `dim0 >= 1000` or `name matches "^wg_.*"`.
+ */
+type filterClause struct {
+	field string
+	op    string
+	value string
+}
+
+/*
+ * filterExpr is a small expression language: clauses joined by `and` bind
+ * tighter than `or`, so `a and b or c and d` reads as `(a and b) or (c
+ * and d)`. No parentheses are supported; that's more than listing needs
+ * today.
+ */
+type filterExpr struct {
+	orOfAnd [][]filterClause
+}
+
+func parseFilter(expr string) (*filterExpr, error) {
+	expr = strings.TrimSpace(expr)
+	if expr == "" {
+		return nil, nil
+	}
+
+	var orOfAnd [][]filterClause
+	for _, orPart := range splitTopLevel(expr, " or ") {
+		var clauses []filterClause
+		for _, andPart := range splitTopLevel(orPart, " and ") {
+			clause, err := parseClause(andPart)
+			if err != nil {
+				return nil, err
+			}
+			clauses = append(clauses, clause)
+		}
+		orOfAnd = append(orOfAnd, clauses)
+	}
+
+	return &filterExpr{orOfAnd: orOfAnd}, nil
+}
+
+// splitTopLevel splits on sep without needing to understand quoting,
+// since none of the fields this filter language supports allow the
+// literal strings " and " or " or " inside a quoted value.
+func splitTopLevel(s string, sep string) []string {
+	parts := strings.Split(s, sep)
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+	return parts
+}
+
+var filterOps = []string{">=", "<=", "!=", ">", "<", "matches", "="}
+
+func parseClause(s string) (filterClause, error) {
+	for _, op := range filterOps {
+		idx := strings.Index(s, " "+op+" ")
+		if idx < 0 {
+			continue
+		}
+		field := strings.TrimSpace(s[:idx])
+		value := strings.Trim(strings.TrimSpace(s[idx+len(op)+2:]), `"`)
+		return filterClause{field: field, op: op, value: value}, nil
+	}
+	return filterClause{}, fmt.Errorf("invalid filter clause %q", s)
+}
+
+func (f *filterExpr) match(m cubeMeta) bool {
+	if f == nil {
+		return true
+	}
+
+	for _, clauses := range f.orOfAnd {
+		allMatch := true
+		for _, c := range clauses {
+			if !c.match(m) {
+				allMatch = false
+				break
+			}
+		}
+		if allMatch {
+			return true
+		}
+	}
+	return false
+}
+
+func (c filterClause) match(m cubeMeta) bool {
+	switch c.field {
+	case "name":
+		return matchString(m.name, c.op, c.value)
+	case "dim0":
+		return matchInt(m.dim0, c.op, c.value)
+	case "updated":
+		return matchTime(m.updated, c.op, c.value)
+	default:
+		return false
+	}
+}
+
+func matchString(got string, op string, value string) bool {
+	switch op {
+	case "=":
+		return got == value
+	case "!=":
+		return got != value
+	case "matches":
+		return regexpMatch(value, got)
+	default:
+		return false
+	}
+}
+
+func matchInt(got int, op string, value string) bool {
+	want, err := strconv.Atoi(value)
+	if err != nil {
+		return false
+	}
+	switch op {
+	case "=":
+		return got == want
+	case "!=":
+		return got != want
+	case ">":
+		return got > want
+	case ">=":
+		return got >= want
+	case "<":
+		return got < want
+	case "<=":
+		return got <= want
+	default:
+		return false
+	}
+}
+
+func matchTime(got time.Time, op string, value string) bool {
+	want, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return false
+	}
+	switch op {
+	case "=":
+		return got.Equal(want)
+	case "!=":
+		return !got.Equal(want)
+	case ">":
+		return got.After(want)
+	case ">=":
+		return !got.Before(want)
+	case "<":
+		return got.Before(want)
+	case "<=":
+		return !got.After(want)
+	default:
+		return false
+	}
+}
+
+/*
+ * sortCubes orders cubes by the sort key, descending when prefixed with
+ * "-", with guid as a tiebreaker so pagination stays deterministic.
+ */
+func sortCubes(cubes []cubeMeta, sortKey string) {
+	descending := strings.HasPrefix(sortKey, "-")
+	key := strings.TrimPrefix(sortKey, "-")
+
+	less := func(i, j int) bool {
+		a, b := cubes[i], cubes[j]
+		switch key {
+		case "updated":
+			if !a.updated.Equal(b.updated) {
+				return a.updated.Before(b.updated)
+			}
+		case "name":
+			fallthrough
+		default:
+			if a.name != b.name {
+				return a.name < b.name
+			}
+		}
+		return a.guid < b.guid
+	}
+
+	sort.Slice(cubes, func(i, j int) bool {
+		if descending {
+			return less(j, i)
+		}
+		return less(i, j)
+	})
+}
+
+/*
+ * listCursor is the opaque continuation token: the sort key and guid of
+ * the last entry returned, so the next page can resume from there
+ * regardless of which replica serves the request.
+ */
+type listCursor struct {
+	sortKey string
+	guid    string
+}
+
+func encodeCursor(c listCursor) string {
+	raw := c.sortKey + "\x00" + c.guid
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(s string) (listCursor, error) {
+	if s == "" {
+		return listCursor{}, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return listCursor{}, fmt.Errorf("invalid cursor: %w", err)
+	}
+
+	parts := strings.SplitN(string(raw), "\x00", 2)
+	if len(parts) != 2 {
+		return listCursor{}, fmt.Errorf("invalid cursor")
+	}
+	return listCursor{sortKey: parts[0], guid: parts[1]}, nil
+}
+
+func regexpMatch(pattern string, s string) bool {
+	ok, err := regexp.MatchString(pattern, s)
+	return err == nil && ok
+}
+
+// sortKeyOf returns the value sortCubes would have ordered m by, so a
+// cursor can be compared against it without re-deriving sort logic.
+func sortKeyOf(m cubeMeta, sortKey string) string {
+	switch strings.TrimPrefix(sortKey, "-") {
+	case "updated":
+		return m.updated.UTC().Format(time.RFC3339)
+	default:
+		return m.name
+	}
+}
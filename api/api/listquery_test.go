@@ -0,0 +1,91 @@
+package api
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseListQueryDefaults(t *testing.T) {
+	q, err := ParseListQuery("", "", "", "")
+	if err != nil {
+		t.Fatalf("ParseListQuery: %v", err)
+	}
+	if q.Sort != "name" || q.Limit != defaultListLimit {
+		t.Fatalf("unexpected defaults: %+v", q)
+	}
+}
+
+func TestParseListQueryRejectsInvalidLimit(t *testing.T) {
+	if _, err := ParseListQuery("", "", "not-a-number", ""); err == nil {
+		t.Fatalf("expected an error for a non-numeric limit")
+	}
+}
+
+func TestFilterMatchesNameAndDim0(t *testing.T) {
+	f, err := parseFilter(`name matches "^wg_.*" and dim0 >= 1000`)
+	if err != nil {
+		t.Fatalf("parseFilter: %v", err)
+	}
+
+	if !f.match(cubeMeta{name: "wg_0042", dim0: 1500}) {
+		t.Fatalf("expected match")
+	}
+	if f.match(cubeMeta{name: "wg_0042", dim0: 999}) {
+		t.Fatalf("expected no match: dim0 below threshold")
+	}
+	if f.match(cubeMeta{name: "other", dim0: 1500}) {
+		t.Fatalf("expected no match: name doesn't match pattern")
+	}
+}
+
+func TestFilterOrOfAnd(t *testing.T) {
+	f, err := parseFilter(`name = "a" or name = "b"`)
+	if err != nil {
+		t.Fatalf("parseFilter: %v", err)
+	}
+
+	if !f.match(cubeMeta{name: "a"}) || !f.match(cubeMeta{name: "b"}) {
+		t.Fatalf("expected both a and b to match")
+	}
+	if f.match(cubeMeta{name: "c"}) {
+		t.Fatalf("expected c to not match")
+	}
+}
+
+func TestFilterUpdatedComparison(t *testing.T) {
+	f, err := parseFilter(`updated > 2024-01-01`)
+	if err != nil {
+		t.Fatalf("parseFilter: %v", err)
+	}
+
+	cutoff, _ := time.Parse("2006-01-02", "2024-01-01")
+	if !f.match(cubeMeta{updated: cutoff.Add(24 * time.Hour)}) {
+		t.Fatalf("expected a later date to match")
+	}
+	if f.match(cubeMeta{updated: cutoff}) {
+		t.Fatalf("expected the boundary date to not match a strict >")
+	}
+}
+
+func TestSortCubesDeterministicTiebreak(t *testing.T) {
+	cubes := []cubeMeta{
+		{name: "a", guid: "2"},
+		{name: "a", guid: "1"},
+	}
+	sortCubes(cubes, "name")
+
+	if cubes[0].guid != "1" || cubes[1].guid != "2" {
+		t.Fatalf("expected guid to break ties, got %+v", cubes)
+	}
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	c := listCursor{sortKey: "wg_0042", guid: "guid-1"}
+	decoded, err := decodeCursor(encodeCursor(c))
+	if err != nil {
+		t.Fatalf("decodeCursor: %v", err)
+	}
+	if decoded != c {
+		t.Fatalf("decodeCursor(encodeCursor(c)) = %+v, want %+v", decoded, c)
+	}
+}
@@ -0,0 +1,54 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis"
+	"go.opentelemetry.io/otel"
+
+	"github.com/equinor/oneseismic/api/internal/auth"
+)
+
+var resultTracer = otel.Tracer("github.com/equinor/oneseismic/api/api")
+
+/*
+ * Result answers /result/:pid by polling redis for the compute result a
+ * worker writes back under pid, up to Timeout.
+ */
+type Result struct {
+	Timeout    time.Duration
+	StorageURL string
+	Storage    redis.Cmdable
+	Keyring    *auth.Keyring
+}
+
+const resultPollInterval = 100 * time.Millisecond
+
+func (r *Result) Get(ctx *gin.Context) {
+	pid := ctx.Param("pid")
+
+	reqCtx, span := resultTracer.Start(ctx.Request.Context(), "api.Result.Get")
+	defer span.End()
+
+	deadline := time.Now().Add(r.Timeout)
+	for time.Now().Before(deadline) {
+		_, pollSpan := resultTracer.Start(reqCtx, "api.Result.poll")
+		raw, err := r.Storage.Get(pid).Result()
+		pollSpan.End()
+
+		if err == nil {
+			ctx.Data(http.StatusOK, "application/json", []byte(raw))
+			return
+		}
+		if err != redis.Nil {
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		time.Sleep(resultPollInterval)
+	}
+
+	ctx.AbortWithStatus(http.StatusGatewayTimeout)
+}
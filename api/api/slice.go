@@ -0,0 +1,113 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pebbe/zmq4"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+
+	"github.com/equinor/oneseismic/api/internal/auth"
+	"github.com/equinor/oneseismic/api/internal/message"
+	"github.com/equinor/oneseismic/api/internal/util"
+)
+
+var sliceTracer = otel.Tracer("github.com/equinor/oneseismic/api/api")
+
+/*
+ * Slice answers /query/:guid/slice/:dimension/:lineno by building a
+ * message.Task describing the requested slice and handing it off to a
+ * compute worker over the ZMQ PUSH socket; the result itself is fetched
+ * later from /result/:pid.
+ */
+type Slice struct {
+	keyring    *auth.Keyring
+	storageURL string
+	out        *zmq4.Socket
+}
+
+func MakeSlice(keyring *auth.Keyring, storageURL string, out *zmq4.Socket) Slice {
+	return Slice{
+		keyring:    keyring,
+		storageURL: storageURL,
+		out:        out,
+	}
+}
+
+func (s *Slice) Get(ctx *gin.Context) {
+	reqCtx, span := sliceTracer.Start(ctx.Request.Context(), "api.Slice.Get")
+	defer span.End()
+
+	pid := ctx.GetString("pid")
+	guid := ctx.Param("guid")
+
+	dimension, err := strconv.Atoi(ctx.Param("dimension"))
+	if err != nil {
+		ctx.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	lineno, err := strconv.Atoi(ctx.Param("lineno"))
+	if err != nil {
+		ctx.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	endpoint := ctx.GetString("Endpoint")
+	token := ctx.GetString("Token")
+
+	m, err := util.GetManifest(reqCtx, endpoint, guid)
+	if err != nil {
+		log.Printf("pid=%s %v", pid, err)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	shape := make([]int32, len(m.Dimensions))
+	for i, dim := range m.Dimensions {
+		shape[i] = int32(len(dim))
+	}
+	shapecube := make([]int32, len(shape))
+	copy(shapecube, shape)
+	shapecube[dimension] = int32(lineno)
+
+	manifest, err := json.Marshal(m)
+	if err != nil {
+		log.Printf("pid=%s %v", pid, err)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	task := message.Task{
+		Pid:             pid,
+		Token:           token,
+		Guid:            guid,
+		StorageEndpoint: endpoint,
+		Manifest:        string(manifest),
+		Shape:           shape,
+		ShapeCube:       shapecube,
+	}
+
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(reqCtx, carrier)
+	task.TraceParent = carrier.Get("traceparent")
+	task.TraceState = carrier.Get("tracestate")
+
+	body, err := json.Marshal(task)
+	if err != nil {
+		log.Printf("pid=%s %v", pid, err)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if _, err := s.out.SendBytes(body, 0); err != nil {
+		log.Printf("pid=%s %v", pid, err)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.JSON(http.StatusAccepted, gin.H{"pid": pid})
+}
@@ -1,17 +1,22 @@
 package main
 
 import (
+	"context"
+	"crypto"
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"time"
 
 	"github.com/equinor/oneseismic/api/api"
 	"github.com/equinor/oneseismic/api/internal/auth"
+	"github.com/equinor/oneseismic/api/internal/tracing"
 	"github.com/gin-gonic/gin"
 	"github.com/go-redis/redis"
 	"github.com/namsral/flag"
 	"github.com/pebbe/zmq4"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
 type opts struct {
@@ -23,6 +28,10 @@ type opts struct {
 	redisURL     string
 	bind         string
 	signkey      string
+	authConnector string
+	tokenCacheBackend string
+	tracingExporter string
+	tracingSampleRate float64
 }
 
 func parseopts() (opts, error) {
@@ -79,6 +88,30 @@ func parseopts() (opts, error) {
 	for _, opt := range params {
 		flag.StringVar(opt.param, opt.flag, "", opt.help)
 	}
+	flag.StringVar(
+		&opts.authConnector,
+		"auth-connector",
+		"azuread",
+		"Identity provider connector: azuread, keycloak, or oidc",
+	)
+	flag.StringVar(
+		&opts.tokenCacheBackend,
+		"token-cache-backend",
+		"memory",
+		"On-behalf-of storage token cache backend: memory or redis",
+	)
+	flag.StringVar(
+		&opts.tracingExporter,
+		"tracing-exporter",
+		"none",
+		"OpenTelemetry trace exporter: otlp, jaeger, stdout, or none",
+	)
+	flag.Float64Var(
+		&opts.tracingSampleRate,
+		"tracing-sample-rate",
+		1.0,
+		"Fraction of requests to trace when tracing is enabled",
+	)
 	flag.Parse()
 	for _, opt := range params {
 		if *opt.param == "" {
@@ -156,6 +189,16 @@ func main() {
 		log.Fatalf("Unable to start server: %v", err)
 	}
 
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Config {
+		Exporter:    opts.tracingExporter,
+		SampleRate:  opts.tracingSampleRate,
+		ServiceName: "oneseismic-query",
+	})
+	if err != nil {
+		log.Fatalf("Unable to set up tracing: %v", err)
+	}
+	defer shutdownTracing(context.Background())
+
 	httpclient := http.Client {
 		Timeout: 10 * time.Second,
 	}
@@ -164,7 +207,22 @@ func main() {
 		opts.authserver + "/v2.0/.well-known/openid-configuration",
 	)
 	if err != nil {
-		log.Fatalf("Unable to get OpenID keyset: %v", err)
+		log.Fatalf("Unable to get OpenID configuration: %v", err)
+	}
+
+	/*
+	 * keySet is refreshed in the background so that a tenant rotating its
+	 * signing keys doesn't require restarting oneseismic, mirroring
+	 * server.WithOAuth2's iris-side keyset.
+	 */
+	keySet, err := auth.NewKeySet(
+		func() (map[string]crypto.PublicKey, error) {
+			return auth.FetchJWKS(&httpclient, openidcfg.JwksUri)
+		},
+		0,
+	)
+	if err != nil {
+		log.Fatalf("Unable to set up JWKS keyset: %v", err)
 	}
 
 	out, err := zmq4.NewSocket(zmq4.PUSH)
@@ -177,29 +235,62 @@ func main() {
 	}
 	defer out.Close()
 
+	redisClient := redis.NewClient(&redis.Options {
+		Addr: opts.redisURL,
+		DB: 0,
+	})
+
 	keyring := auth.MakeKeyring([]byte(opts.signkey))
 	slice := api.MakeSlice(&keyring, opts.storageURL, out)
 	result := api.Result {
 		Timeout: time.Second * 15,
 		StorageURL: opts.storageURL,
-		Storage: redis.NewClient(&redis.Options {
-			Addr: opts.redisURL,
-			DB: 0,
-		}),
+		Storage: redisClient,
 		Keyring: &keyring,
 	}
 
+	var tokenCache auth.TokenCache
+	switch opts.tokenCacheBackend {
+	case "redis":
+		tokenCache = auth.NewRedisTokenCache(redisClient)
+	default:
+		tokenCache = auth.NewInMemoryTokenCache(0)
+	}
+
+	authServerURL, err := url.Parse(opts.authserver)
+	if err != nil {
+		log.Fatalf("Unable to parse authserver as a URL: %v", err)
+	}
+	connector, err := auth.NewConnector(opts.authConnector, auth.ConnectorOptions {
+		AuthServer:    authServerURL,
+		Issuer:        openidcfg.Issuer,
+		Audience:      opts.audience,
+		ClientID:      opts.clientID,
+		ClientSecret:  opts.clientSecret,
+		TokenEndpoint: openidcfg.TokenEndpoint,
+		HTTPClient:    &httpclient,
+		KeySet:        keySet,
+	})
+	if err != nil {
+		log.Fatalf("Unable to set up auth connector: %v", err)
+	}
+
+	clientCfg := connector.ClientConfig()
 	cfg := clientconfig {
-		appid: opts.clientID,
-		authority: opts.authserver,
-		scopes: []string{
-			fmt.Sprintf("api://%s/One.Read", opts.clientID),
-		},
+		appid: clientCfg.ClientID,
+		authority: clientCfg.Authority,
+		scopes: clientCfg.Scopes,
 	}
 
-	validate := auth.ValidateJWT(openidcfg.Jwks, openidcfg.Issuer, opts.audience)
-	onbehalf := auth.OnBehalfOf(openidcfg.TokenEndpoint, opts.clientID, opts.clientSecret)
+	/*
+	 * validate and onbehalf both go through connector, so that picking
+	 * AUTH_CONNECTOR=keycloak or oidc changes how requests are validated
+	 * and exchanged, not just what /config advertises to clients.
+	 */
+	validate := auth.ConnectorMiddleware(connector)
+	onbehalf := auth.CachedExchange(tokenCache, connector, "https://storage.azure.com/.default")
 	app := gin.Default()
+	app.Use(otelgin.Middleware("oneseismic-query"))
 	app.GET(
 		"/query/:guid/slice/:dimension/:lineno",
 		validate,
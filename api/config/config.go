@@ -28,6 +28,9 @@ func SetDefaults() {
 	viper.SetDefault("LETSENCRYPT", false)
 	viper.SetDefault("PROFILING", false)
 	viper.SetDefault("SWAGGER", false)
+	viper.SetDefault("AUTH_CONNECTOR", "azuread")
+	viper.SetDefault("TRACING_EXPORTER", "none")
+	viper.SetDefault("TRACING_SAMPLE_RATE", 1.0)
 }
 
 func Load() error {
@@ -123,3 +126,29 @@ func Profiling() bool {
 func Swagger() bool {
 	return viper.GetBool("SWAGGER")
 }
+
+/*
+ * AuthConnector selects the internal/auth.Connector implementation to
+ * authenticate against: azuread (default), keycloak, or oidc. This lets
+ * oneseismic be deployed against identity providers other than Azure AD
+ * without forking the auth code.
+ */
+func AuthConnector() string {
+	return viper.GetString("AUTH_CONNECTOR")
+}
+
+/*
+ * TracingExporter selects the OpenTelemetry span exporter: otlp, jaeger,
+ * stdout, or none (the default, which disables tracing entirely).
+ */
+func TracingExporter() string {
+	return viper.GetString("TRACING_EXPORTER")
+}
+
+/*
+ * TracingSampleRate is the fraction of requests (0.0-1.0) that get
+ * traced when tracing is enabled.
+ */
+func TracingSampleRate() float64 {
+	return viper.GetFloat64("TRACING_SAMPLE_RATE")
+}
@@ -0,0 +1,193 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+/*
+ * Claims is the subset of a validated token's claims that oneseismic
+ * cares about, independent of which identity provider issued it.
+ */
+type Claims struct {
+	Subject string
+	Oid     string
+}
+
+/*
+ * ClientConfig is what a Connector tells the /config endpoint to hand to
+ * the python client library, so it knows how to obtain a token this
+ * connector will accept.
+ */
+type ClientConfig struct {
+	ClientID  string
+	Authority string
+	Scopes    []string
+}
+
+/*
+ * Connector abstracts the identity-provider-specific parts of
+ * authentication: validating an incoming bearer token, and (where
+ * applicable) exchanging it for a storage-scoped token. This lets
+ * oneseismic be deployed against identity providers other than Azure AD
+ * without forking the auth code.
+ */
+type Connector interface {
+	// Validate checks a raw bearer token and returns its claims.
+	Validate(ctx context.Context, rawToken string) (Claims, error)
+
+	// ExchangeForStorage turns a validated user token into a token that
+	// storage will accept. Connectors that front a storage backend
+	// without an on-behalf-of step (e.g. keycloak, oidc) may pass the
+	// user token straight through.
+	ExchangeForStorage(ctx context.Context, userToken string) (storageToken string, exp time.Time, err error)
+
+	// ClientConfig returns the connector-appropriate client_id, authority
+	// and scopes for the /config endpoint.
+	ClientConfig() ClientConfig
+}
+
+/*
+ * passthroughExchange is implemented by connectors whose
+ * ExchangeForStorage returns the caller's own token with no real expiry
+ * (keycloak, genericOIDC): such a token can never be served from
+ * CachedExchange's cache, so it's checked via a type assertion there and
+ * the cache is skipped entirely rather than recording permanent misses.
+ */
+type passthroughExchange interface {
+	passthroughExchange()
+}
+
+/*
+ * ConnectorOptions carries the parameters NewConnector passes to the
+ * selected connector.
+ */
+type ConnectorOptions struct {
+	AuthServer    *url.URL
+	Issuer        string
+	Audience      string
+	ClientID      string
+	ClientSecret  string
+	TokenEndpoint string
+	HTTPClient    *http.Client
+	// KeySet verifies a token's signature in validateWithKeySet. It's
+	// required for Validate to succeed; callers build it from the same
+	// discovery document used to populate Issuer/TokenEndpoint.
+	KeySet *KeySet
+}
+
+/*
+ * NewConnector selects a Connector implementation by name, as configured
+ * through AUTH_CONNECTOR. opts carries the parameters every connector
+ * needs; individual connectors ignore the ones they don't use (e.g.
+ * keycloak and oidc have no on-behalf-of step).
+ */
+func NewConnector(name string, opts ConnectorOptions) (Connector, error) {
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	switch name {
+	case "", "azuread":
+		return newAzureAD(opts), nil
+	case "keycloak":
+		return newKeycloak(opts), nil
+	case "oidc":
+		return newGenericOIDC(opts), nil
+	default:
+		return nil, fmt.Errorf("unknown AUTH_CONNECTOR=%q", name)
+	}
+}
+
+/*
+ * azureAD is the original oneseismic behaviour: MSAL-shaped scopes,
+ * an on-behalf-of exchange against the Microsoft token endpoint, and an
+ * authority shaped like login.microsoftonline.com/<tenant>.
+ */
+type azureAD struct {
+	opts ConnectorOptions
+}
+
+func newAzureAD(opts ConnectorOptions) *azureAD {
+	return &azureAD{opts: opts}
+}
+
+func (a *azureAD) Validate(ctx context.Context, rawToken string) (Claims, error) {
+	return validateWithKeySet(ctx, a.opts, rawToken)
+}
+
+func (a *azureAD) ExchangeForStorage(ctx context.Context, userToken string) (string, time.Time, error) {
+	return onBehalfOfExchange(ctx, a.opts, userToken)
+}
+
+func (a *azureAD) ClientConfig() ClientConfig {
+	return ClientConfig{
+		ClientID:  a.opts.ClientID,
+		Authority: a.opts.AuthServer.String(),
+		Scopes:    []string{fmt.Sprintf("api://%s/One.Read", a.opts.ClientID)},
+	}
+}
+
+/*
+ * keycloak validates a realm-issued token and passes it straight through
+ * to storage; it has no on-behalf-of step and requests the
+ * offline_access scope so the client library can refresh silently.
+ */
+type keycloak struct {
+	opts ConnectorOptions
+}
+
+func newKeycloak(opts ConnectorOptions) *keycloak {
+	return &keycloak{opts: opts}
+}
+
+func (k *keycloak) Validate(ctx context.Context, rawToken string) (Claims, error) {
+	return validateWithKeySet(ctx, k.opts, rawToken)
+}
+
+func (k *keycloak) ExchangeForStorage(_ context.Context, userToken string) (string, time.Time, error) {
+	return userToken, time.Time{}, nil
+}
+
+func (k *keycloak) passthroughExchange() {}
+
+func (k *keycloak) ClientConfig() ClientConfig {
+	return ClientConfig{
+		ClientID:  k.opts.ClientID,
+		Authority: k.opts.AuthServer.String(),
+		Scopes:    []string{"openid", "offline_access"},
+	}
+}
+
+/*
+ * genericOIDC is the fallback for any standards-compliant provider:
+ * discovery + JWKS validation, no vendor-specific token exchange.
+ */
+type genericOIDC struct {
+	opts ConnectorOptions
+}
+
+func newGenericOIDC(opts ConnectorOptions) *genericOIDC {
+	return &genericOIDC{opts: opts}
+}
+
+func (o *genericOIDC) Validate(ctx context.Context, rawToken string) (Claims, error) {
+	return validateWithKeySet(ctx, o.opts, rawToken)
+}
+
+func (o *genericOIDC) ExchangeForStorage(_ context.Context, userToken string) (string, time.Time, error) {
+	return userToken, time.Time{}, nil
+}
+
+func (o *genericOIDC) passthroughExchange() {}
+
+func (o *genericOIDC) ClientConfig() ClientConfig {
+	return ClientConfig{
+		ClientID:  o.opts.ClientID,
+		Authority: o.opts.AuthServer.String(),
+		Scopes:    []string{"openid"},
+	}
+}
@@ -0,0 +1,59 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+/*
+ * ConnectorMiddleware validates the caller's bearer token through conn
+ * and mints a per-request pid used to correlate this query with its
+ * result at /result/:pid. The validated subject (conn's Claims.Oid) is
+ * stored separately in the gin context, so downstream middleware - the
+ * on-behalf-of token cache, in particular - can key on stable user
+ * identity rather than the per-request pid.
+ */
+func ConnectorMiddleware(conn Connector) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		raw := strings.TrimPrefix(ctx.GetHeader("Authorization"), "Bearer ")
+		if raw == "" {
+			ctx.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+
+		claims, err := conn.Validate(ctx, raw)
+		if err != nil {
+			ctx.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+
+		pid, err := newPid()
+		if err != nil {
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		ctx.Set("pid", pid)
+		ctx.Set("oid", claims.Oid)
+		ctx.Set("user-jwt", raw)
+		ctx.Next()
+	}
+}
+
+/*
+ * newPid mints the per-request correlation id returned to callers for
+ * polling /result/:pid. It's independent of the caller's identity so
+ * that identical requests from the same user don't collide.
+ */
+func newPid() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("generating pid: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,145 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"net/url"
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+func testOpts(t *testing.T) ConnectorOptions {
+	t.Helper()
+	authServer, err := url.Parse("https://login.microsoftonline.com/tenant-id")
+	if err != nil {
+		t.Fatalf("parsing test authServer: %v", err)
+	}
+	return ConnectorOptions{
+		AuthServer: authServer,
+		ClientID:   "client-id",
+	}
+}
+
+func TestNewConnectorSelectsImplementation(t *testing.T) {
+	cases := []struct {
+		name string
+		want interface{}
+	}{
+		{"", &azureAD{}},
+		{"azuread", &azureAD{}},
+		{"keycloak", &keycloak{}},
+		{"oidc", &genericOIDC{}},
+	}
+
+	for _, c := range cases {
+		conn, err := NewConnector(c.name, testOpts(t))
+		if err != nil {
+			t.Fatalf("NewConnector(%q): %v", c.name, err)
+		}
+
+		switch c.want.(type) {
+		case *azureAD:
+			if _, ok := conn.(*azureAD); !ok {
+				t.Fatalf("NewConnector(%q) = %T, want *azureAD", c.name, conn)
+			}
+		case *keycloak:
+			if _, ok := conn.(*keycloak); !ok {
+				t.Fatalf("NewConnector(%q) = %T, want *keycloak", c.name, conn)
+			}
+		case *genericOIDC:
+			if _, ok := conn.(*genericOIDC); !ok {
+				t.Fatalf("NewConnector(%q) = %T, want *genericOIDC", c.name, conn)
+			}
+		}
+	}
+
+	if _, err := NewConnector("unknown", testOpts(t)); err == nil {
+		t.Fatalf("expected error for unknown connector name")
+	}
+}
+
+func TestAzureADClientConfigScopesByClientID(t *testing.T) {
+	conn, err := NewConnector("azuread", testOpts(t))
+	if err != nil {
+		t.Fatalf("NewConnector: %v", err)
+	}
+
+	cc := conn.ClientConfig()
+	want := "api://client-id/One.Read"
+	if len(cc.Scopes) != 1 || cc.Scopes[0] != want {
+		t.Fatalf("ClientConfig().Scopes = %v, want [%s]", cc.Scopes, want)
+	}
+}
+
+func TestKeycloakExchangeForStoragePassesTokenThrough(t *testing.T) {
+	conn, err := NewConnector("keycloak", testOpts(t))
+	if err != nil {
+		t.Fatalf("NewConnector: %v", err)
+	}
+
+	token, _, err := conn.ExchangeForStorage(nil, "user-token")
+	if err != nil {
+		t.Fatalf("ExchangeForStorage: %v", err)
+	}
+	if token != "user-token" {
+		t.Fatalf("ExchangeForStorage() = %q, want passthrough of user token", token)
+	}
+}
+
+func TestConnectorValidateRejectsForgedSignature(t *testing.T) {
+	legitKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating legitimate key: %v", err)
+	}
+	attackerKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating attacker key: %v", err)
+	}
+
+	ks := &KeySet{grace: time.Minute}
+	ks.fetch = func() (map[string]crypto.PublicKey, error) {
+		return map[string]crypto.PublicKey{"kid-a": &legitKey.PublicKey}, nil
+	}
+	if err := ks.refresh(); err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+
+	opts := testOpts(t)
+	opts.Issuer = "https://issuer.example.com"
+	opts.Audience = "api://client-id"
+	opts.KeySet = ks
+
+	conn, err := NewConnector("oidc", opts)
+	if err != nil {
+		t.Fatalf("NewConnector: %v", err)
+	}
+
+	sign := func(key *rsa.PrivateKey) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+			"iss": opts.Issuer,
+			"aud": opts.Audience,
+			"oid": "user-oid",
+			"exp": time.Now().Add(time.Hour).Unix(),
+		})
+		token.Header["kid"] = "kid-a"
+		signed, err := token.SignedString(key)
+		if err != nil {
+			t.Fatalf("signing token: %v", err)
+		}
+		return signed
+	}
+
+	legitToken := sign(legitKey)
+	if _, err := conn.Validate(context.Background(), legitToken); err != nil {
+		t.Fatalf("expected a genuinely signed token to validate, got: %v", err)
+	}
+
+	forgedToken := sign(attackerKey)
+	if _, err := conn.Validate(context.Background(), forgedToken); err == nil {
+		t.Fatalf("expected a token forged with a different key (same kid) to be rejected")
+	}
+}
@@ -0,0 +1,156 @@
+package auth
+
+import (
+	"crypto"
+	"fmt"
+	"sync"
+	"time"
+
+	l "github.com/equinor/oneseismic/api/logger"
+)
+
+const (
+	defaultRefreshInterval = time.Hour
+	defaultGracePeriod     = 10 * time.Minute
+	minRefetchInterval     = time.Minute
+)
+
+/*
+ * FetchKeys knows how to pull the current signing keyset for a single
+ * issuer, keyed by kid. It's a function rather than e.g. an interface so
+ * that both the OIDC-discovery lookup used in production (a closure over
+ * FetchJWKS or GetOIDCKeySet) and a canned map used in tests can serve as
+ * a KeySet's fetch.
+ */
+type FetchKeys func() (map[string]crypto.PublicKey, error)
+
+/*
+ * KeySet holds the RSA signing keys published by an OIDC provider,
+ * refreshed periodically in the background so that a tenant rotating its
+ * signing keys doesn't require restarting oneseismic. The previous
+ * generation of keys is kept around for a grace period after a refresh,
+ * so tokens signed just before a rotation still validate until they'd
+ * have expired anyway.
+ */
+type KeySet struct {
+	mu         sync.RWMutex
+	keys       map[string]crypto.PublicKey
+	prev       map[string]crypto.PublicKey
+	prevExpiry time.Time
+
+	fetch    FetchKeys
+	interval time.Duration
+	grace    time.Duration
+
+	onDemandMu   sync.Mutex
+	lastOnDemand time.Time
+}
+
+/*
+ * NewKeySet performs an initial fetch and starts the background refresh
+ * loop. interval <= 0 falls back to defaultRefreshInterval.
+ */
+func NewKeySet(fetch FetchKeys, interval time.Duration) (*KeySet, error) {
+	if interval <= 0 {
+		interval = defaultRefreshInterval
+	}
+
+	ks := &KeySet{
+		fetch:    fetch,
+		interval: interval,
+		grace:    defaultGracePeriod,
+	}
+
+	if err := ks.refresh(); err != nil {
+		return nil, fmt.Errorf("initial keyset fetch: %w", err)
+	}
+
+	go ks.refreshLoop()
+	return ks, nil
+}
+
+func (ks *KeySet) refreshLoop() {
+	ticker := time.NewTicker(ks.interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := ks.refresh(); err != nil {
+			l.LogE("refreshing JWKS", err)
+		}
+	}
+}
+
+/*
+ * refresh re-fetches the keyset and atomically swaps it in, demoting the
+ * outgoing generation to prev rather than dropping it immediately.
+ */
+func (ks *KeySet) refresh() error {
+	keys, err := ks.fetch()
+	if err != nil {
+		return err
+	}
+
+	ks.mu.Lock()
+	ks.prev = ks.keys
+	ks.prevExpiry = time.Now().Add(ks.grace)
+	ks.keys = keys
+	ks.mu.Unlock()
+
+	return nil
+}
+
+/*
+ * Key looks up a public key by kid. If the kid is unknown it triggers an
+ * out-of-band refresh, rate-limited to at most one per minRefetchInterval
+ * so a flood of tokens with bogus kids can't be used to force repeated
+ * fetches against the provider.
+ */
+func (ks *KeySet) Key(kid string) (crypto.PublicKey, bool) {
+	if key, ok := ks.lookup(kid); ok {
+		return key, true
+	}
+
+	if ks.tryRefetch() {
+		return ks.lookup(kid)
+	}
+	return nil, false
+}
+
+func (ks *KeySet) lookup(kid string) (crypto.PublicKey, bool) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	if key, ok := ks.keys[kid]; ok {
+		return key, true
+	}
+	if time.Now().Before(ks.prevExpiry) {
+		if key, ok := ks.prev[kid]; ok {
+			return key, true
+		}
+	}
+	return nil, false
+}
+
+/*
+ * tryRefetch rate-limits on-demand refreshes on their own clock, separate
+ * from the periodic refreshLoop: lastOnDemand is only ever touched here,
+ * so a periodic (or the initial) refresh never counts against the
+ * on-demand budget. Without that separation, the first unknown-kid
+ * lookup after any periodic refresh would be rate-limited away for up to
+ * a full interval instead of only repeat on-demand attempts.
+ */
+func (ks *KeySet) tryRefetch() bool {
+	ks.onDemandMu.Lock()
+	if time.Since(ks.lastOnDemand) < minRefetchInterval {
+		ks.onDemandMu.Unlock()
+		return false
+	}
+	ks.lastOnDemand = time.Now()
+	ks.onDemandMu.Unlock()
+
+	if err := ks.refresh(); err != nil {
+		l.LogE("on-demand JWKS refresh", err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,111 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func fakeRSAKey(e int) *rsa.PublicKey {
+	return &rsa.PublicKey{N: big.NewInt(1), E: e}
+}
+
+func TestKeySetRotation(t *testing.T) {
+	oldKey := fakeRSAKey(3)
+	newKey := fakeRSAKey(5)
+	generation := 0
+
+	// lastOnDemand is set to now so that Key's on-demand refresh is
+	// rate-limited for this test's duration: only the explicit refresh
+	// calls below rotate the keyset.
+	ks := &KeySet{grace: 50 * time.Millisecond, lastOnDemand: time.Now()}
+	ks.fetch = func() (map[string]crypto.PublicKey, error) {
+		generation++
+		if generation == 1 {
+			return map[string]crypto.PublicKey{"old-kid": oldKey}, nil
+		}
+		return map[string]crypto.PublicKey{"new-kid": newKey}, nil
+	}
+
+	if err := ks.refresh(); err != nil {
+		t.Fatalf("initial refresh: %v", err)
+	}
+
+	if _, ok := ks.Key("new-kid"); ok {
+		t.Fatalf("new-kid should not be known before rotation")
+	}
+
+	if err := ks.refresh(); err != nil {
+		t.Fatalf("rotation refresh: %v", err)
+	}
+
+	if key, ok := ks.Key("old-kid"); !ok || key != crypto.PublicKey(oldKey) {
+		t.Fatalf("old-kid should still validate during the grace period")
+	}
+	if key, ok := ks.Key("new-kid"); !ok || key != crypto.PublicKey(newKey) {
+		t.Fatalf("new-kid should validate immediately after refresh")
+	}
+
+	time.Sleep(75 * time.Millisecond)
+	if _, ok := ks.Key("old-kid"); ok {
+		t.Fatalf("old-kid should be rejected once the grace period expires")
+	}
+}
+
+func TestKeySetUnknownKidTriggersRefresh(t *testing.T) {
+	fetches := 0
+	ks := &KeySet{grace: time.Minute}
+	ks.fetch = func() (map[string]crypto.PublicKey, error) {
+		fetches++
+		return map[string]crypto.PublicKey{"kid-a": fakeRSAKey(3)}, nil
+	}
+
+	if err := ks.refresh(); err != nil {
+		t.Fatalf("initial refresh: %v", err)
+	}
+	if fetches != 1 {
+		t.Fatalf("expected 1 fetch, got %d", fetches)
+	}
+
+	// Unknown kid triggers an on-demand refresh once, but a second lookup
+	// shortly after is rate-limited rather than hitting the provider again.
+	ks.Key("kid-unknown")
+	if fetches != 2 {
+		t.Fatalf("expected on-demand refresh, got %d fetches", fetches)
+	}
+
+	ks.Key("kid-still-unknown")
+	if fetches != 2 {
+		t.Fatalf("expected rate limit to suppress refresh, got %d fetches", fetches)
+	}
+}
+
+func TestKeySetOnDemandRefreshNotBlockedByPriorPeriodicRefresh(t *testing.T) {
+	fetches := 0
+	ks := &KeySet{grace: time.Minute}
+	ks.fetch = func() (map[string]crypto.PublicKey, error) {
+		fetches++
+		return map[string]crypto.PublicKey{"kid-a": fakeRSAKey(3)}, nil
+	}
+
+	// A periodic refresh (as refreshLoop or NewKeySet's initial fetch would
+	// perform) must not consume the on-demand rate-limit budget.
+	if err := ks.refresh(); err != nil {
+		t.Fatalf("periodic refresh: %v", err)
+	}
+	if err := ks.refresh(); err != nil {
+		t.Fatalf("second periodic refresh: %v", err)
+	}
+	if fetches != 2 {
+		t.Fatalf("expected 2 periodic fetches, got %d", fetches)
+	}
+
+	if _, ok := ks.Key("kid-unknown"); ok {
+		t.Fatalf("kid-unknown should not be known")
+	}
+	if fetches != 3 {
+		t.Fatalf("expected the first on-demand lookup to refresh immediately, got %d fetches", fetches)
+	}
+}
@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"go.opentelemetry.io/otel"
+)
+
+var oboTracer = otel.Tracer("github.com/equinor/oneseismic/api/internal/auth")
+
+/*
+ * onBehalfOfExchange performs the Azure AD on-behalf-of token exchange:
+ * trade the caller's bearer token for one scoped to blob storage, using
+ * this deployment's client credentials.
+ */
+func onBehalfOfExchange(ctx context.Context, opts ConnectorOptions, userToken string) (string, time.Time, error) {
+	ctx, span := oboTracer.Start(ctx, "auth.onBehalfOfExchange")
+	defer span.End()
+
+	userToken = strings.TrimPrefix(userToken, "Bearer ")
+
+	form := url.Values{}
+	form.Set("grant_type", "urn:ietf:params:oauth:grant-type:jwt-bearer")
+	form.Set("client_id", opts.ClientID)
+	form.Set("client_secret", opts.ClientSecret)
+	form.Set("assertion", userToken)
+	form.Set("scope", "https://storage.azure.com/.default")
+	form.Set("requested_token_use", "on_behalf_of")
+
+	req, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodPost,
+		opts.TokenEndpoint,
+		strings.NewReader(form.Encode()),
+	)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("building on-behalf-of request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := opts.HTTPClient.Do(req)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("on-behalf-of exchange: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", time.Time{}, fmt.Errorf("on-behalf-of exchange: unexpected status %s", resp.Status)
+	}
+
+	var body struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", time.Time{}, fmt.Errorf("decoding on-behalf-of response: %w", err)
+	}
+
+	exp, err := tokenExpiry(body.AccessToken)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("reading storage token expiry: %w", err)
+	}
+
+	return body.AccessToken, exp, nil
+}
+
+func tokenExpiry(rawToken string) (time.Time, error) {
+	token, _, err := new(jwt.Parser).ParseUnverified(rawToken, jwt.MapClaims{})
+	if err != nil {
+		return time.Time{}, err
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return time.Time{}, fmt.Errorf("unexpected claims type")
+	}
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return time.Time{}, fmt.Errorf("token has no exp claim")
+	}
+	return time.Unix(int64(exp), 0), nil
+}
@@ -0,0 +1,301 @@
+package auth
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"golang.org/x/sync/singleflight"
+
+	l "github.com/equinor/oneseismic/api/logger"
+)
+
+const (
+	// cacheSkew is subtracted from a cached token's exp before it's
+	// considered usable, so a request never races a token expiring
+	// mid-flight.
+	cacheSkew = 2 * time.Minute
+	// refreshFraction is the remaining-lifetime fraction below which a
+	// cache hit triggers a background single-flight refresh.
+	refreshFraction = 0.2
+
+	defaultCacheCapacity = 10000
+)
+
+var (
+	cacheHits = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "oneseismic_obo_token_cache_hits_total",
+		Help: "Number of on-behalf-of storage token cache hits",
+	})
+	cacheMisses = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "oneseismic_obo_token_cache_misses_total",
+		Help: "Number of on-behalf-of storage token cache misses",
+	})
+	refreshFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "oneseismic_obo_token_refresh_failures_total",
+		Help: "Number of failed on-behalf-of storage token refreshes",
+	})
+)
+
+/*
+ * CachedToken is a storage token together with the bookkeeping needed to
+ * decide when it should be served from cache and when it's due for a
+ * background refresh.
+ */
+type CachedToken struct {
+	Token    string
+	Exp      time.Time
+	IssuedAt time.Time
+}
+
+func (c CachedToken) usable() bool {
+	return time.Now().Before(c.Exp.Add(-cacheSkew))
+}
+
+func (c CachedToken) dueForRefresh() bool {
+	lifetime := c.Exp.Sub(c.IssuedAt)
+	if lifetime <= 0 {
+		return false
+	}
+	return time.Until(c.Exp) < time.Duration(float64(lifetime)*refreshFraction)
+}
+
+/*
+ * TokenCache stores on-behalf-of storage tokens keyed by TokenCacheKey,
+ * so that slice.Get doesn't pay for a full AAD token exchange on every
+ * request. NewInMemoryTokenCache is the default; NewRedisTokenCache lets
+ * the cache be shared across replicas.
+ */
+type TokenCache interface {
+	Get(ctx context.Context, key string) (CachedToken, bool)
+	Set(ctx context.Context, key string, token CachedToken) error
+}
+
+/*
+ * TokenCacheKey hashes (user-oid, audience, resource) into a stable,
+ * fixed-length cache key.
+ */
+func TokenCacheKey(userOid, audience, resource string) string {
+	sum := sha256.Sum256([]byte(userOid + "|" + audience + "|" + resource))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+type lruEntry struct {
+	key   string
+	token CachedToken
+}
+
+type lruTokenCache struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List
+	items    map[string]*list.Element
+}
+
+/*
+ * NewInMemoryTokenCache returns the default TokenCache: an in-process LRU
+ * bounded to capacity entries (0 means defaultCacheCapacity).
+ */
+func NewInMemoryTokenCache(capacity int) TokenCache {
+	if capacity <= 0 {
+		capacity = defaultCacheCapacity
+	}
+	return &lruTokenCache{
+		capacity: capacity,
+		order:    list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+func (c *lruTokenCache) Get(_ context.Context, key string) (CachedToken, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return CachedToken{}, false
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(*lruEntry).token, true
+}
+
+func (c *lruTokenCache) Set(_ context.Context, key string, token CachedToken) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		el.Value.(*lruEntry).token = token
+		c.order.MoveToFront(el)
+		return nil
+	}
+
+	c.items[key] = c.order.PushFront(&lruEntry{key: key, token: token})
+	if c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*lruEntry).key)
+	}
+	return nil
+}
+
+type redisRecord struct {
+	Token    string    `json:"token"`
+	Exp      time.Time `json:"exp"`
+	IssuedAt time.Time `json:"issued_at"`
+}
+
+type redisTokenCache struct {
+	client *redis.Client
+	prefix string
+}
+
+/*
+ * NewRedisTokenCache shares the on-behalf-of token cache across API
+ * replicas, reusing the redis.Client already constructed in main for
+ * query results.
+ */
+func NewRedisTokenCache(client *redis.Client) TokenCache {
+	return &redisTokenCache{client: client, prefix: "obo-token:"}
+}
+
+func (c *redisTokenCache) Get(_ context.Context, key string) (CachedToken, bool) {
+	raw, err := c.client.Get(c.prefix + key).Result()
+	if err != nil {
+		return CachedToken{}, false
+	}
+
+	var rec redisRecord
+	if err := json.Unmarshal([]byte(raw), &rec); err != nil {
+		return CachedToken{}, false
+	}
+	return CachedToken{Token: rec.Token, Exp: rec.Exp, IssuedAt: rec.IssuedAt}, true
+}
+
+func (c *redisTokenCache) Set(_ context.Context, key string, token CachedToken) error {
+	raw, err := json.Marshal(redisRecord{
+		Token:    token.Token,
+		Exp:      token.Exp,
+		IssuedAt: token.IssuedAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	ttl := time.Until(token.Exp)
+	if ttl <= 0 {
+		ttl = time.Minute
+	}
+	return c.client.Set(c.prefix+key, raw, ttl).Err()
+}
+
+/*
+ * CachedExchange serves conn.ExchangeForStorage results from cache when
+ * they're still fresh, kicks off a background single-flight refresh once
+ * a cached token is within refreshFraction of expiry, and only blocks
+ * the request on a real exchange on a genuine cache miss. It works
+ * against any Connector, so switching AUTH_CONNECTOR also changes how
+ * (or whether) this exchange happens - azuread performs a real
+ * on-behalf-of grant, keycloak and oidc pass the user token through.
+ *
+ * The cache key is built from the validated caller's oid (set by
+ * ConnectorMiddleware), not the per-request pid: pid is a fresh
+ * correlation id minted for every request to poll /result/:pid, so
+ * keying on it would mean every request is a cache miss.
+ *
+ * Connectors whose ExchangeForStorage passes the caller's token straight
+ * through (keycloak, oidc) never return a real expiry, so a cached entry
+ * is never usable() and the cache can never hit. CachedExchange detects
+ * this via passthroughExchange and skips the cache entirely for those
+ * connectors, rather than paying for a Get/Set on every request and
+ * reporting permanent 100% misses on oneseismic_obo_token_cache_*_total.
+ */
+func CachedExchange(cache TokenCache, conn Connector, audience string) gin.HandlerFunc {
+	var group singleflight.Group
+	_, passthrough := conn.(passthroughExchange)
+
+	return func(ctx *gin.Context) {
+		userToken := strings.TrimPrefix(ctx.GetHeader("Authorization"), "Bearer ")
+
+		if passthrough {
+			token, _, err := conn.ExchangeForStorage(ctx, userToken)
+			if err != nil {
+				ctx.AbortWithError(http.StatusBadGateway, err)
+				return
+			}
+			ctx.Set("Token", token)
+			ctx.Next()
+			return
+		}
+
+		oid := ctx.GetString("oid")
+		key := TokenCacheKey(oid, audience, ctx.Param("guid"))
+
+		if cached, ok := cache.Get(ctx, key); ok && cached.usable() {
+			cacheHits.Inc()
+			if cached.dueForRefresh() {
+				go backgroundRefresh(&group, cache, conn, userToken, key)
+			}
+			ctx.Set("Token", cached.Token)
+			ctx.Next()
+			return
+		}
+		cacheMisses.Inc()
+
+		v, err, _ := group.Do(key, func() (interface{}, error) {
+			return exchangeAndCache(ctx, cache, conn, userToken, key)
+		})
+		if err != nil {
+			refreshFailures.Inc()
+			ctx.AbortWithError(http.StatusBadGateway, err)
+			return
+		}
+
+		ctx.Set("Token", v.(CachedToken).Token)
+		ctx.Next()
+	}
+}
+
+func exchangeAndCache(
+	ctx context.Context,
+	cache TokenCache,
+	conn Connector,
+	userToken string,
+	key string,
+) (CachedToken, error) {
+	token, exp, err := conn.ExchangeForStorage(ctx, userToken)
+	if err != nil {
+		return CachedToken{}, err
+	}
+
+	cached := CachedToken{Token: token, Exp: exp, IssuedAt: time.Now()}
+	if err := cache.Set(ctx, key, cached); err != nil {
+		l.LogE("caching on-behalf-of token", err)
+	}
+	return cached, nil
+}
+
+func backgroundRefresh(
+	group *singleflight.Group,
+	cache TokenCache,
+	conn Connector,
+	userToken string,
+	key string,
+) {
+	_, err, _ := group.Do(key, func() (interface{}, error) {
+		return exchangeAndCache(context.Background(), cache, conn, userToken, key)
+	})
+	if err != nil {
+		refreshFailures.Inc()
+		l.LogE("background on-behalf-of refresh", err)
+	}
+}
@@ -0,0 +1,157 @@
+package auth
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestInMemoryTokenCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := NewInMemoryTokenCache(2)
+	ctx := context.Background()
+
+	cache.Set(ctx, "a", CachedToken{Token: "a-token"})
+	cache.Set(ctx, "b", CachedToken{Token: "b-token"})
+
+	// Touch "a" so "b" becomes the least recently used entry.
+	cache.Get(ctx, "a")
+	cache.Set(ctx, "c", CachedToken{Token: "c-token"})
+
+	if _, ok := cache.Get(ctx, "b"); ok {
+		t.Fatalf("expected b to be evicted")
+	}
+	if _, ok := cache.Get(ctx, "a"); !ok {
+		t.Fatalf("expected a to still be cached")
+	}
+	if _, ok := cache.Get(ctx, "c"); !ok {
+		t.Fatalf("expected c to be cached")
+	}
+}
+
+func TestCachedTokenUsableRespectsSkew(t *testing.T) {
+	fresh := CachedToken{Exp: time.Now().Add(time.Hour)}
+	if !fresh.usable() {
+		t.Fatalf("expected a token expiring in an hour to be usable")
+	}
+
+	aboutToExpire := CachedToken{Exp: time.Now().Add(cacheSkew / 2)}
+	if aboutToExpire.usable() {
+		t.Fatalf("expected a token inside the skew window to be unusable")
+	}
+}
+
+func TestCachedTokenDueForRefresh(t *testing.T) {
+	now := time.Now()
+	token := CachedToken{
+		IssuedAt: now.Add(-50 * time.Minute),
+		Exp:      now.Add(10 * time.Minute),
+	}
+	if !token.dueForRefresh() {
+		t.Fatalf("expected a token with 10/60 min remaining to be due for refresh")
+	}
+
+	token = CachedToken{
+		IssuedAt: now.Add(-10 * time.Minute),
+		Exp:      now.Add(50 * time.Minute),
+	}
+	if token.dueForRefresh() {
+		t.Fatalf("expected a freshly issued token to not be due for refresh")
+	}
+}
+
+/*
+ * fakeConnector counts ExchangeForStorage calls so tests can assert on
+ * cache hits without a real identity provider.
+ */
+type fakeConnector struct {
+	exchanges int
+	token     string
+	exp       time.Time
+}
+
+func (f *fakeConnector) Validate(context.Context, string) (Claims, error) {
+	return Claims{}, nil
+}
+
+func (f *fakeConnector) ExchangeForStorage(context.Context, string) (string, time.Time, error) {
+	f.exchanges++
+	return f.token, f.exp, nil
+}
+
+func (f *fakeConnector) ClientConfig() ClientConfig {
+	return ClientConfig{}
+}
+
+/*
+ * fakePassthroughConnector is a fakeConnector that also implements
+ * passthroughExchange, so CachedExchange skips the cache for it the way
+ * it does for keycloak and genericOIDC.
+ */
+type fakePassthroughConnector struct {
+	fakeConnector
+}
+
+func (f *fakePassthroughConnector) passthroughExchange() {}
+
+func TestCachedExchangeSkipsCacheForPassthroughConnector(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cache := NewInMemoryTokenCache(0)
+	conn := &fakePassthroughConnector{fakeConnector{token: "user-token"}}
+	handler := CachedExchange(cache, conn, "https://storage.azure.com/.default")
+
+	for _, pid := range []string{"pid-1", "pid-2"} {
+		w := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(w)
+		ctx.Request = httptest.NewRequest("GET", "/query/guid-1/slice/0/1", nil)
+		ctx.Request.Header.Set("Authorization", "Bearer user-token")
+		ctx.Params = gin.Params{{Key: "guid", Value: "guid-1"}}
+		ctx.Set("pid", pid)
+		ctx.Set("oid", "user-oid")
+		handler(ctx)
+	}
+
+	if conn.exchanges != 2 {
+		t.Fatalf(
+			"expected every request to call ExchangeForStorage directly for a passthrough connector, got %d exchanges",
+			conn.exchanges,
+		)
+	}
+	if _, ok := cache.Get(context.Background(), TokenCacheKey("user-oid", "https://storage.azure.com/.default", "guid-1")); ok {
+		t.Fatalf("expected passthrough exchange to never populate the cache")
+	}
+}
+
+func TestCachedExchangeKeysByOidNotPid(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cache := NewInMemoryTokenCache(0)
+	conn := &fakeConnector{token: "storage-token", exp: time.Now().Add(time.Hour)}
+	handler := CachedExchange(cache, conn, "https://storage.azure.com/.default")
+
+	request := func(pid string) {
+		w := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(w)
+		ctx.Request = httptest.NewRequest("GET", "/query/guid-1/slice/0/1", nil)
+		ctx.Request.Header.Set("Authorization", "Bearer user-token")
+		ctx.Params = gin.Params{{Key: "guid", Value: "guid-1"}}
+		// pid is the per-request correlation id minted by
+		// ConnectorMiddleware for every request; oid is the stable,
+		// validated caller identity it also sets. The cache must key on
+		// the latter.
+		ctx.Set("pid", pid)
+		ctx.Set("oid", "user-oid")
+		handler(ctx)
+	}
+
+	request("pid-1")
+	request("pid-2")
+
+	if conn.exchanges != 1 {
+		t.Fatalf(
+			"expected the second request (different pid, same oid) to be served from cache, got %d exchanges",
+			conn.exchanges,
+		)
+	}
+}
@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+/*
+ * validateWithKeySet is the common bearer-token check shared by the
+ * connectors that speak plain OIDC (azuread, keycloak, oidc): parse the
+ * token, verify its RS256 signature against opts.KeySet, and check
+ * issuer/audience match this deployment.
+ */
+func validateWithKeySet(ctx context.Context, opts ConnectorOptions, rawToken string) (Claims, error) {
+	rawToken = strings.TrimPrefix(rawToken, "Bearer ")
+	if rawToken == "" {
+		return Claims{}, fmt.Errorf("empty bearer token")
+	}
+	if opts.KeySet == nil {
+		return Claims{}, fmt.Errorf("connector has no keyset configured")
+	}
+
+	token, err := jwt.Parse(rawToken, func(t *jwt.Token) (interface{}, error) {
+		if t.Method.Alg() != "RS256" {
+			return nil, fmt.Errorf("unexpected jwt signing method=%v", t.Header["alg"])
+		}
+		kid, ok := t.Header["kid"].(string)
+		if !ok {
+			return nil, fmt.Errorf("token header missing kid")
+		}
+		key, ok := opts.KeySet.Key(kid)
+		if !ok {
+			return nil, fmt.Errorf("unknown kid=%s", kid)
+		}
+		return key, nil
+	})
+	if err != nil {
+		return Claims{}, fmt.Errorf("parsing token: %w", err)
+	}
+	if !token.Valid {
+		return Claims{}, fmt.Errorf("invalid token signature")
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return Claims{}, fmt.Errorf("unexpected claims type")
+	}
+
+	if opts.Issuer != "" && !claims.VerifyIssuer(opts.Issuer, true) {
+		return Claims{}, fmt.Errorf("unexpected issuer")
+	}
+	if opts.Audience != "" && !claims.VerifyAudience(opts.Audience, true) {
+		return Claims{}, fmt.Errorf("unexpected audience")
+	}
+
+	sub, _ := claims["sub"].(string)
+	oid, _ := claims["oid"].(string)
+	if oid == "" {
+		oid = sub
+	}
+
+	return Claims{Subject: sub, Oid: oid}, nil
+}
@@ -0,0 +1,33 @@
+package message
+
+/*
+ * Task is the unit of work sent to the compute workers over the ZMQ PUSH
+ * socket. The worker reads Manifest to know the cube's layout, cuts out
+ * Shape from ShapeCube, and uses Token/StorageEndpoint to fetch the
+ * underlying blobs on the caller's behalf.
+ *
+ * TraceParent/TraceState carry the W3C trace context of the API request
+ * that produced this task, so the worker's compute spans link back to
+ * the request that triggered them.
+ */
+type Task struct {
+	Pid             string
+	Token           string
+	Guid            string
+	StorageEndpoint string
+	Manifest        string
+	Shape           []int32
+	ShapeCube       []int32
+	TraceParent     string
+	TraceState      string
+}
+
+/*
+ * DimensionDescription describes a single dimension of a cube, as
+ * reported to clients by the root and entry endpoints.
+ */
+type DimensionDescription struct {
+	Dimension int
+	Size      int
+	Keys      []int
+}
@@ -0,0 +1,66 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/jaeger"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+/*
+ * Config selects which OpenTelemetry trace exporter oneseismic reports
+ * spans to, and at what sample rate, driven by the TRACING_* settings.
+ */
+type Config struct {
+	// Exporter is one of "otlp", "jaeger", "stdout", or "none".
+	Exporter    string
+	SampleRate  float64
+	ServiceName string
+}
+
+/*
+ * Setup installs the global TracerProvider and W3C trace-context
+ * propagator for cfg, returning a shutdown func that flushes pending
+ * spans. Exporter "none" (the default) installs no provider and leaves
+ * otel's default no-op provider in place, so tracer.Start calls
+ * elsewhere in oneseismic stay cheap when tracing isn't configured.
+ */
+func Setup(ctx context.Context, cfg Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	if cfg.Exporter == "" || cfg.Exporter == "none" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := newExporter(ctx, cfg.Exporter)
+	if err != nil {
+		return nil, err
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.TraceIDRatioBased(cfg.SampleRate)),
+	)
+	otel.SetTracerProvider(tp)
+
+	return tp.Shutdown, nil
+}
+
+func newExporter(ctx context.Context, name string) (sdktrace.SpanExporter, error) {
+	switch name {
+	case "otlp":
+		return otlptrace.New(ctx, otlptracegrpc.NewClient())
+	case "jaeger":
+		return jaeger.New(jaeger.WithCollectorEndpoint())
+	case "stdout":
+		return stdouttrace.New()
+	default:
+		return nil, fmt.Errorf("unknown TRACING_EXPORTER=%q", name)
+	}
+}
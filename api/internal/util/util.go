@@ -0,0 +1,91 @@
+package util
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"go.opentelemetry.io/otel"
+)
+
+var tracer = otel.Tracer("github.com/equinor/oneseismic/api/internal/util")
+
+/*
+ * Manifest describes a cube's layout: one set of keys per dimension.
+ * Dimensions[i] is the ordered set of line numbers/keys along dimension
+ * i, so len(Dimensions[i]) is that dimension's size. Name and Updated are
+ * metadata used for listing/filtering, not computation.
+ */
+type Manifest struct {
+	Name       string
+	Updated    time.Time
+	Dimensions [][]int
+}
+
+/*
+ * GetManifest fetches and parses the manifest for guid from endpoint.
+ */
+func GetManifest(ctx context.Context, endpoint string, guid string) (*Manifest, error) {
+	ctx, span := tracer.Start(ctx, "util.GetManifest")
+	defer span.End()
+
+	req, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodGet,
+		fmt.Sprintf("%s/%s/manifest.json", endpoint, guid),
+		nil,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("building manifest request: %w", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching manifest: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching manifest: unexpected status %s", resp.Status)
+	}
+
+	var m Manifest
+	if err := json.NewDecoder(resp.Body).Decode(&m); err != nil {
+		return nil, fmt.Errorf("decoding manifest: %w", err)
+	}
+	return &m, nil
+}
+
+/*
+ * ListCubes lists the guids of every cube the caller's token gives it
+ * access to at endpoint.
+ */
+func ListCubes(ctx context.Context, endpoint *url.URL, token string) ([]string, error) {
+	ctx, span := tracer.Start(ctx, "util.ListCubes")
+	defer span.End()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint.String()+"?comp=list", nil)
+	if err != nil {
+		return nil, fmt.Errorf("building list request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("listing cubes: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("listing cubes: unexpected status %s", resp.Status)
+	}
+
+	var cubes []string
+	if err := json.NewDecoder(resp.Body).Decode(&cubes); err != nil {
+		return nil, fmt.Errorf("decoding cube list: %w", err)
+	}
+	return cubes, nil
+}
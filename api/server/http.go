@@ -1,12 +1,14 @@
 package server
 
 import (
+	"crypto"
 	"fmt"
 	"net/http"
 	pprof "net/http/pprof"
 
 	jwt "github.com/dgrijalva/jwt-go"
 	_ "github.com/equinor/oneseismic/api/docs" // docs is generated by Swag CLI, you have to import it.
+	"github.com/equinor/oneseismic/api/internal/auth"
 	l "github.com/equinor/oneseismic/api/logger"
 	claimsmiddleware "github.com/equinor/oneseismic/api/middleware/claims"
 	jwtmiddleware "github.com/iris-contrib/middleware/jwt"
@@ -16,6 +18,7 @@ import (
 	"github.com/kataras/iris/v12"
 	irisCtx "github.com/kataras/iris/v12/context"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel"
 )
 
 type HTTPServer struct {
@@ -47,6 +50,17 @@ func Create(c Config) (*HTTPServer, error) {
 }
 
 func Configure(hs *HTTPServer, opts ...HTTPServerOption) error {
+	/*
+	 * otelMiddleware has to be registered before any opt applies its own
+	 * middleware (WithOAuth2's authHandler/claimsHandler.Validate in
+	 * particular): iris runs Use middleware in registration order, so
+	 * registering it first is what makes the span cover auth latency and
+	 * still get produced for requests auth aborts with a 401. This
+	 * mirrors cmd/query/main.go, where otelgin.Middleware is registered
+	 * before validate/onbehalf for the same reason.
+	 */
+	hs.app.Use(otelMiddleware(otel.Tracer("github.com/equinor/oneseismic/api/server")))
+
 	for _, opt := range opts {
 		err := opt.apply(hs)
 		if err != nil {
@@ -63,18 +77,44 @@ func Configure(hs *HTTPServer, opts ...HTTPServerOption) error {
 func WithOAuth2(oauthOpt OAuth2Option) HTTPServerOption {
 
 	return newFuncOption(func(hs *HTTPServer) error {
-		sigKeySet, err := GetOIDCKeySet(oauthOpt.AuthServer)
+		keySet, err := auth.NewKeySet(
+			func() (map[string]crypto.PublicKey, error) {
+				return GetOIDCKeySet(oauthOpt.AuthServer)
+			},
+			oauthOpt.JWKSRefreshInterval,
+		)
 		if err != nil {
 			return fmt.Errorf("Couldn't get keyset: %v", err)
 		}
 
+		/*
+		 * This iris app only needs a Connector to fail fast on a
+		 * misconfigured AUTH_CONNECTOR at startup: signature and
+		 * issuer/audience checks below are already identity-provider
+		 * agnostic, and this app doesn't perform an on-behalf-of
+		 * exchange (that's cmd/query's job, via auth.CachedExchange).
+		 */
+		if _, err := auth.NewConnector(oauthOpt.AuthConnector, auth.ConnectorOptions{
+			AuthServer: oauthOpt.AuthServer,
+			Issuer:     oauthOpt.Issuer,
+			Audience:   oauthOpt.Audience,
+			KeySet:     keySet,
+		}); err != nil {
+			return fmt.Errorf("configuring auth connector: %v", err)
+		}
+
 		rsaJWTHandler := jwtmiddleware.New(jwtmiddleware.Config{
 			ValidationKeyGetter: func(t *jwt.Token) (interface{}, error) {
 
 				if t.Method.Alg() != "RS256" {
 					return nil, fmt.Errorf("unexpected jwt signing method=%v", t.Header["alg"])
 				}
-				return sigKeySet[t.Header["kid"].(string)], nil
+				kid, _ := t.Header["kid"].(string)
+				key, ok := keySet.Key(kid)
+				if !ok {
+					return nil, fmt.Errorf("unknown kid=%v", kid)
+				}
+				return key, nil
 
 			},
 			ContextKey:    "user-jwt",
@@ -107,7 +147,7 @@ func WithOAuth2(oauthOpt OAuth2Option) HTTPServerOption {
 
 		claimsHandler := claimsmiddleware.New(oauthOpt.Audience, oauthOpt.Issuer)
 
-		auth := func(ctx irisCtx.Context) {
+		authHandler := func(ctx irisCtx.Context) {
 			hmacJWTHandler.Serve(ctx)
 			serviceToken := ctx.Values().Get("service-jwt")
 			if serviceToken == nil {
@@ -115,7 +155,7 @@ func WithOAuth2(oauthOpt OAuth2Option) HTTPServerOption {
 			}
 
 		}
-		hs.app.Use(auth)
+		hs.app.Use(authHandler)
 		hs.app.Use(claimsHandler.Validate)
 		return nil
 	})
@@ -0,0 +1,29 @@
+package server
+
+import (
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
+	irisCtx "github.com/kataras/iris/v12/context"
+)
+
+/*
+ * otelMiddleware extracts an incoming W3C traceparent/tracestate header
+ * (if any) and starts a server span per request, so requests that arrive
+ * with a trace already in progress (e.g. from a client or a gateway) link
+ * up rather than starting a disconnected trace.
+ */
+func otelMiddleware(tracer trace.Tracer) irisCtx.Handler {
+	return func(ctx irisCtx.Context) {
+		propagator := otel.GetTextMapPropagator()
+		carrier := propagation.HeaderCarrier(ctx.Request().Header)
+		parentCtx := propagator.Extract(ctx.Request().Context(), carrier)
+
+		spanCtx, span := tracer.Start(parentCtx, ctx.Path())
+		defer span.End()
+
+		ctx.ResetRequest(ctx.Request().WithContext(spanCtx))
+		ctx.Next()
+	}
+}